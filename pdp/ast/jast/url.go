@@ -0,0 +1,160 @@
+package jast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/infobloxopen/themis/pdp"
+)
+
+// ErrNotModified is returned by UnmarshalFromURL when the source reports
+// (via ETag/If-None-Match) that the policy hasn't changed since the last
+// fetch, so the caller can skip re-parsing.
+var ErrNotModified = errors.New("jast: policy source not modified")
+
+// SourceOptions configures how UnmarshalFromURL reaches a remote policy
+// source. A nil *SourceOptions is equivalent to the zero value.
+type SourceOptions struct {
+	// HTTPClient is used for http(s):// sources. http.DefaultClient is
+	// used if it's nil.
+	HTTPClient *http.Client
+	// Headers are added to the http(s):// request.
+	Headers http.Header
+	// BearerToken, if set, is sent as an "Authorization: Bearer <token>"
+	// header on http(s):// requests.
+	BearerToken string
+	// IfNoneMatch, if set, is sent as the If-None-Match header on
+	// http(s):// requests; a matching ETag makes UnmarshalFromURL return
+	// ErrNotModified instead of fetching and parsing the body again.
+	IfNoneMatch string
+	// ETag, if not nil, is set to the ETag of a successful response so the
+	// caller can pass it as IfNoneMatch on the next poll.
+	ETag *string
+}
+
+// Unmarshal fetches the policy at u, parses it with UnmarshalFromURL and
+// returns the root policy. u may be a file://, http(s):// or
+// s3://bucket/key URL.
+func Unmarshal(u string, opts *SourceOptions) (pdp.Evaluable, error) {
+	ctx := newContext()
+	if err := ctx.UnmarshalFromURL(u, opts); err != nil {
+		return nil, err
+	}
+
+	return ctx.rootPolicy, nil
+}
+
+// UnmarshalFromURL loads the policy document at u and decodes it into ctx,
+// dispatching on u's scheme: file://, http(s):// or s3://bucket/key.
+func (ctx *context) UnmarshalFromURL(u string, opts *SourceOptions) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+
+	var body io.ReadCloser
+	switch parsed.Scheme {
+	case "file":
+		body, err = openFileSource(parsed)
+
+	case "http", "https":
+		body, err = fetchHTTPSource(u, opts)
+
+	case "s3":
+		body, err = fetchS3Source(parsed)
+
+	default:
+		return fmt.Errorf("jast: unsupported policy source scheme %q", parsed.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return ctx.unmarshal(json.NewDecoder(body))
+}
+
+func openFileSource(u *url.URL) (io.ReadCloser, error) {
+	path := u.Path
+	if u.Host != "" {
+		path = u.Host + path
+	}
+
+	return os.Open(path)
+}
+
+func fetchHTTPSource(u string, opts *SourceOptions) (io.ReadCloser, error) {
+	client := http.DefaultClient
+	if opts != nil && opts.HTTPClient != nil {
+		client = opts.HTTPClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts != nil {
+		for k, values := range opts.Headers {
+			for _, v := range values {
+				req.Header.Add(k, v)
+			}
+		}
+
+		if opts.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+opts.BearerToken)
+		}
+
+		if opts.IfNoneMatch != "" {
+			req.Header.Set("If-None-Match", opts.IfNoneMatch)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		return nil, ErrNotModified
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("jast: GET %s: unexpected status %s", u, resp.Status)
+	}
+
+	if opts != nil && opts.ETag != nil {
+		*opts.ETag = resp.Header.Get("ETag")
+	}
+
+	return resp.Body, nil
+}
+
+func fetchS3Source(u *url.URL) (io.ReadCloser, error) {
+	sess, err := session.NewSession(aws.NewConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(u.Host),
+		Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Body, nil
+}
@@ -0,0 +1,84 @@
+package jast
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPolicyJSON = `{}`
+
+func TestUnmarshalFromURL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.json")
+	if err := os.WriteFile(path, []byte(testPolicyJSON), 0644); err != nil {
+		t.Fatalf("writing test policy file: %s", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testPolicyJSON))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "file", url: "file://" + path},
+		{name: "http", url: srv.URL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := newContext()
+			if err := ctx.UnmarshalFromURL(test.url, nil); err != nil {
+				t.Fatalf("UnmarshalFromURL(%q) returned error: %s", test.url, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalFromURLUnsupportedScheme(t *testing.T) {
+	ctx := newContext()
+	err := ctx.UnmarshalFromURL("ftp://example.com/policy.json", nil)
+	if err == nil {
+		t.Fatal("expected an error for an unsupported scheme, got nil")
+	}
+}
+
+func TestUnmarshalFromURLHTTPNotModified(t *testing.T) {
+	const etag = `"v1"`
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(testPolicyJSON))
+	}))
+	defer srv.Close()
+
+	var gotETag string
+	opts := &SourceOptions{ETag: &gotETag}
+
+	ctx := newContext()
+	if err := ctx.UnmarshalFromURL(srv.URL, opts); err != nil {
+		t.Fatalf("first UnmarshalFromURL() returned error: %s", err)
+	}
+
+	if gotETag != etag {
+		t.Fatalf("expected ETag to round-trip as %q, got %q", etag, gotETag)
+	}
+
+	opts.IfNoneMatch = gotETag
+
+	ctx = newContext()
+	err := ctx.UnmarshalFromURL(srv.URL, opts)
+	if err != ErrNotModified {
+		t.Fatalf("expected ErrNotModified on matching ETag, got: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/openzipkin/zipkin-go-opentracing/thrift/gen-go/zipkincore"
+)
+
+// fakeZipkinCollector records every span handed to it so tests can assert
+// on what actually reached the downstream collector.
+type fakeZipkinCollector struct {
+	mu     sync.Mutex
+	spans  []*zipkincore.Span
+	closed bool
+}
+
+func (f *fakeZipkinCollector) Collect(span *zipkincore.Span) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.spans = append(f.spans, span)
+	return nil
+}
+
+func (f *fakeZipkinCollector) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeZipkinCollector) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.spans)
+}
+
+func (f *fakeZipkinCollector) isClosed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func TestBufferedCollectorDrainsOnClose(t *testing.T) {
+	next := &fakeZipkinCollector{}
+	c := newBufferedCollector(next, 10)
+
+	for i := 0; i < 5; i++ {
+		if err := c.Collect(&zipkincore.Span{}); err != nil {
+			t.Fatalf("Collect() returned error: %s", err)
+		}
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() returned error: %s", err)
+	}
+
+	if n := next.len(); n != 5 {
+		t.Fatalf("expected all 5 buffered spans to be drained to next before Close() returns, got %d", n)
+	}
+
+	if !next.isClosed() {
+		t.Fatal("expected Close() to close next only after draining")
+	}
+}
+
+func TestBufferedCollectorCollectAfterCloseDropsInsteadOfPanicking(t *testing.T) {
+	next := &fakeZipkinCollector{}
+	c := newBufferedCollector(next, 10)
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() returned error: %s", err)
+	}
+
+	if err := c.Collect(&zipkincore.Span{}); err != nil {
+		t.Fatalf("Collect() after Close() returned error: %s", err)
+	}
+
+	if d := c.Dropped(); d != 1 {
+		t.Fatalf("expected Collect() after Close() to be counted as dropped, got %d", d)
+	}
+}
+
+func TestBufferedCollectorDropsOnOverflow(t *testing.T) {
+	// Construct the collector directly, without starting run(), so nothing
+	// drains the channel and overflow behavior is deterministic.
+	next := &fakeZipkinCollector{}
+	c := &bufferedCollector{
+		next:  next,
+		spans: make(chan *zipkincore.Span, 1),
+		done:  make(chan struct{}),
+	}
+
+	if err := c.Collect(&zipkincore.Span{}); err != nil {
+		t.Fatalf("Collect() returned error: %s", err)
+	}
+	if err := c.Collect(&zipkincore.Span{}); err != nil {
+		t.Fatalf("Collect() returned error: %s", err)
+	}
+
+	if d := c.Dropped(); d != 1 {
+		t.Fatalf("expected exactly 1 dropped span once the buffer is full, got %d", d)
+	}
+}
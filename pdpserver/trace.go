@@ -1,36 +1,416 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	ot "github.com/opentracing/opentracing-go"
+	zipkinotbridge "github.com/openzipkin-contrib/zipkin-go-opentracing"
+	zipkinv2 "github.com/openzipkin/zipkin-go"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
 	zipkin "github.com/openzipkin/zipkin-go-opentracing"
+	"github.com/openzipkin/zipkin-go-opentracing/thrift/gen-go/zipkincore"
+	jaeger "github.com/uber/jaeger-client-go"
+	jaegercfg "github.com/uber/jaeger-client-go/config"
+	otelbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
 )
 
-func InitTracing(tracingType, tracingEP string) (ot.Tracer, error) {
+// Zipkin span formats supported by the tracingFormat PDP flag.
+const (
+	zipkinFormatV1Thrift = "zipkin-v1-thrift"
+	zipkinFormatV2JSON   = "zipkin-v2-json"
+)
+
+// hostname and instanceID are computed once and stamped on every span as
+// resource attributes, regardless of which tracing backend is in use.
+var (
+	hostname, _ = os.Hostname()
+	instanceID  = hostname + "/" + strconv.Itoa(os.Getpid())
+)
+
+var (
+	tracingServiceName    = flag.String("tracingServiceName", "PDP", "service name reported to the tracing backend")
+	tracingServiceVersion = flag.String("tracingServiceVersion", "", "service version reported to the tracing backend")
+	tracingSamplerType    = flag.String("tracingSamplerType", jaeger.SamplerTypeConst, "Jaeger sampler type (const, probabilistic, ratelimiting, remote)")
+	tracingSamplerParam   = flag.Float64("tracingSamplerParam", 1, "Jaeger sampler parameter (meaning depends on tracingSamplerType)")
+	tracingFormat         = flag.String("tracingFormat", zipkinFormatV1Thrift, "Zipkin span format: zipkin-v1-thrift or zipkin-v2-json")
+	tracingKafkaBuffer    = flag.Int("tracingKafkaBuffer", 1024, "number of spans buffered in front of the Kafka producer for zipkin-kafka tracing; excess spans are dropped")
+)
+
+// InitTracing sets up the tracer selected by tracingType and pointed at
+// tracingEP. extraTags, if non-nil, are merged into the fixed set of
+// resource attributes (service.name, service.version, service.instance.id,
+// host.name, process.pid) stamped on every span the returned tracer
+// produces. It returns an io.Closer the caller must close on shutdown so
+// that any buffered spans are flushed.
+func InitTracing(tracingType, tracingEP string, extraTags map[string]string) (ot.Tracer, io.Closer, error) {
 	if tracingEP == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
 
+	var tracer ot.Tracer
+	var closer io.Closer
+	var err error
+
 	switch tracingType {
 	case "zipkin":
-		return setupZipkin(tracingEP)
+		tracer, closer, err = setupZipkin(tracingEP, *tracingFormat)
+
+	case "zipkin-kafka":
+		tracer, closer, err = setupZipkinKafka(tracingEP)
+
+	case "jaeger":
+		tracer, closer, err = setupJaeger(tracingEP)
+
+	case "otlp", "otlp-grpc", "otlp-http":
+		tracer, closer, err = setupOTLP(tracingType, tracingEP)
+
 	default:
-		return nil, fmt.Errorf("Invalid tracing type: %s", tracingType)
+		return nil, nil, fmt.Errorf("Invalid tracing type: %s", tracingType)
+	}
+	if err != nil {
+		return nil, nil, err
 	}
+
+	return withResourceTags(tracer, extraTags), closer, nil
 }
 
-func setupZipkin(tracingEP string) (ot.Tracer, error) {
+// withResourceTags wraps tracer so that every span it starts is stamped
+// with PDP's resource attributes plus any caller-supplied extra tags. This
+// works uniformly across all tracing backends, since spans created deep in
+// the evaluator go through whatever ot.Tracer InitTracing handed back.
+func withResourceTags(tracer ot.Tracer, extraTags map[string]string) ot.Tracer {
+	tags := map[string]string{
+		"service.name":        *tracingServiceName,
+		"service.version":     *tracingServiceVersion,
+		"service.instance.id": instanceID,
+		"host.name":           hostname,
+		"process.pid":         strconv.Itoa(os.Getpid()),
+	}
+
+	for k, v := range extraTags {
+		tags[k] = v
+	}
+
+	return &tagInjectingTracer{Tracer: tracer, tags: tags}
+}
+
+// tagInjectingTracer stamps a fixed set of tags onto every span it starts.
+type tagInjectingTracer struct {
+	ot.Tracer
+	tags map[string]string
+}
+
+func (t *tagInjectingTracer) StartSpan(operationName string, opts ...ot.StartSpanOption) ot.Span {
+	span := t.Tracer.StartSpan(operationName, opts...)
+	for k, v := range t.tags {
+		span.SetTag(k, v)
+	}
+
+	return span
+}
+
+// setupZipkin dispatches to the Zipkin span format selected by tracingFormat.
+// An empty format defaults to the legacy Thrift v1 wire format so existing
+// deployments keep working unchanged.
+func setupZipkin(tracingEP, tracingFormat string) (ot.Tracer, io.Closer, error) {
+	switch tracingFormat {
+	case "", zipkinFormatV1Thrift:
+		return setupZipkinV1(tracingEP)
+
+	case zipkinFormatV2JSON:
+		return setupZipkinV2(tracingEP)
+
+	default:
+		return nil, nil, fmt.Errorf("Invalid tracing format: %s", tracingFormat)
+	}
+}
+
+func setupZipkinV1(tracingEP string) (ot.Tracer, io.Closer, error) {
 	if strings.Index(tracingEP, "http") == -1 {
 		tracingEP = "http://" + tracingEP + "/api/v1/spans"
 	}
 
 	collector, err := zipkin.NewHTTPCollector(tracingEP)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	recorder := zipkin.NewRecorder(collector, false, "", *tracingServiceName)
+	tracer, err := zipkin.NewTracer(recorder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tracer, collector, nil
+}
+
+// setupZipkinV2 talks the Zipkin v2 JSON span format over HTTP, using the
+// native zipkin-go tracer bridged back onto the OpenTracing API that the
+// rest of PDP is instrumented against.
+func setupZipkinV2(tracingEP string) (ot.Tracer, io.Closer, error) {
+	if strings.Index(tracingEP, "http") == -1 {
+		tracingEP = "http://" + tracingEP + "/api/v2/spans"
+	}
+
+	reporter := zipkinhttp.NewReporter(tracingEP)
+
+	endpoint, err := zipkinv2.NewEndpoint(*tracingServiceName, "")
+	if err != nil {
+		reporter.Close()
+		return nil, nil, err
+	}
+
+	tracer, err := zipkinv2.NewTracer(reporter, zipkinv2.WithLocalEndpoint(endpoint))
+	if err != nil {
+		reporter.Close()
+		return nil, nil, err
+	}
+
+	return zipkinotbridge.Wrap(tracer), reporter, nil
+}
+
+// setupZipkinKafka reports spans to Zipkin via Kafka instead of HTTP.
+// tracingEP is a comma-separated list of brokers, optionally followed by
+// "?topic=..." to override the default topic. Because PDP is on the
+// request hot path, spans are handed to the Kafka producer through a
+// bounded, non-blocking buffer: if the producer falls behind or Kafka is
+// misconfigured, excess spans are dropped rather than stalling policy
+// evaluation.
+func setupZipkinKafka(tracingEP string) (ot.Tracer, io.Closer, error) {
+	brokerList := tracingEP
+	topic := ""
+
+	if i := strings.Index(tracingEP, "?"); i != -1 {
+		brokerList = tracingEP[:i]
+
+		query, err := url.ParseQuery(tracingEP[i+1:])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		topic = query.Get("topic")
 	}
 
-	recorder := zipkin.NewRecorder(collector, false, "", "PDP")
-	return zipkin.NewTracer(recorder)
-}
\ No newline at end of file
+	brokers := strings.Split(brokerList, ",")
+
+	var opts []zipkin.KafkaOption
+	if topic != "" {
+		opts = append(opts, zipkin.KafkaTopic(topic))
+	}
+
+	collector, err := zipkin.NewKafkaCollector(brokers, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buffered := newBufferedCollector(collector, *tracingKafkaBuffer)
+
+	recorder := zipkin.NewRecorder(buffered, false, "", *tracingServiceName)
+	tracer, err := zipkin.NewTracer(recorder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tracer, buffered, nil
+}
+
+// bufferedCollector sits in front of a zipkin.Collector and decouples span
+// submission from the collector's own I/O. Collect() never blocks the
+// caller: spans are dropped (and counted) once the buffer is full, rather
+// than backing up the request path behind a slow or unreachable collector.
+type bufferedCollector struct {
+	next    zipkin.Collector
+	spans   chan *zipkincore.Span
+	dropped uint64
+	done    chan struct{}
+
+	// mu guards closed so that Collect's send and Close's close(c.spans)
+	// can't race: Close takes the write lock, so it only closes the
+	// channel once every in-flight Collect (holding the read lock) has
+	// returned, ruling out a send on a closed channel.
+	mu     sync.RWMutex
+	closed bool
+}
+
+// dropLogInterval is how often newBufferedCollector's background monitor
+// checks for newly dropped spans and logs them.
+const dropLogInterval = 30 * time.Second
+
+func newBufferedCollector(next zipkin.Collector, bufSize int) *bufferedCollector {
+	c := &bufferedCollector{
+		next:  next,
+		spans: make(chan *zipkincore.Span, bufSize),
+		done:  make(chan struct{}),
+	}
+
+	go c.run()
+	go c.logDropped()
+	return c
+}
+
+func (c *bufferedCollector) run() {
+	defer close(c.done)
+	for span := range c.spans {
+		c.next.Collect(span)
+	}
+}
+
+// Dropped returns the number of spans discarded so far, either because the
+// buffer was full or because they arrived after Close.
+func (c *bufferedCollector) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// logDropped periodically surfaces Dropped() so a misconfigured or
+// unreachable Kafka collector shows up in the PDP logs instead of silently
+// swallowing spans. It exits once Close() has been called and run() has
+// drained the buffer.
+func (c *bufferedCollector) logDropped() {
+	ticker := time.NewTicker(dropLogInterval)
+	defer ticker.Stop()
+
+	var last uint64
+	for {
+		select {
+		case <-ticker.C:
+			if n := c.Dropped(); n != last {
+				log.Printf("tracing: dropped %d spans so far due to a full zipkin-kafka span buffer", n)
+				last = n
+			}
+
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *bufferedCollector) Collect(span *zipkincore.Span) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		atomic.AddUint64(&c.dropped, 1)
+		return nil
+	}
+
+	select {
+	case c.spans <- span:
+	default:
+		atomic.AddUint64(&c.dropped, 1)
+	}
+
+	return nil
+}
+
+// Close stops accepting new spans, waits for run() to drain whatever is
+// still buffered, and only then closes next so buffered spans are
+// delivered rather than lost on shutdown.
+func (c *bufferedCollector) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	close(c.spans)
+	c.mu.Unlock()
+
+	<-c.done
+	return c.next.Close()
+}
+
+// setupJaeger builds a Jaeger tracer against tracingEP. If tracingEP looks
+// like an HTTP(S) URL it's treated as the collector endpoint
+// (e.g. "http://jaeger-collector:14268/api/traces"); otherwise it's treated
+// as the Jaeger agent's host:port for UDP reporting.
+func setupJaeger(tracingEP string) (ot.Tracer, io.Closer, error) {
+	reporter := jaegercfg.ReporterConfig{}
+	if strings.Index(tracingEP, "http") == 0 {
+		reporter.CollectorEndpoint = tracingEP
+	} else {
+		reporter.LocalAgentHostPort = tracingEP
+	}
+
+	cfg := jaegercfg.Configuration{
+		ServiceName: *tracingServiceName,
+		Sampler: &jaegercfg.SamplerConfig{
+			Type:  *tracingSamplerType,
+			Param: *tracingSamplerParam,
+		},
+		Reporter: &reporter,
+	}
+
+	tracer, closer, err := cfg.NewTracer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tracer, closer, nil
+}
+
+// setupOTLP builds an OpenTelemetry TracerProvider backed by an OTLP span
+// exporter and wraps it in the OpenTracing bridge, since the rest of PDP is
+// instrumented against the OpenTracing API. tracingType picks the OTLP
+// transport: "otlp" and "otlp-grpc" use gRPC, "otlp-http" uses HTTP.
+func setupOTLP(tracingType, tracingEP string) (ot.Tracer, io.Closer, error) {
+	ctx := context.Background()
+
+	var client otlptrace.Client
+	switch tracingType {
+	case "otlp-http":
+		client = otlptracehttp.NewClient(
+			otlptracehttp.WithEndpoint(tracingEP),
+			otlptracehttp.WithInsecure(),
+		)
+	default:
+		client = otlptracegrpc.NewClient(
+			otlptracegrpc.WithEndpoint(tracingEP),
+			otlptracegrpc.WithInsecure(),
+		)
+	}
+
+	exporter, err := otlptrace.New(ctx, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := resource.New(ctx, resource.WithAttributes(
+		semconv.ServiceNameKey.String(*tracingServiceName),
+		semconv.ServiceVersionKey.String(*tracingServiceVersion),
+		semconv.HostNameKey.String(hostname),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+
+	bridgeTracer, _ := otelbridge.NewTracerPair(tp.Tracer(*tracingServiceName))
+	return bridgeTracer, &otelProviderCloser{tp}, nil
+}
+
+// otelProviderCloser adapts an OpenTelemetry TracerProvider's Shutdown to
+// the io.Closer interface InitTracing's callers expect.
+type otelProviderCloser struct {
+	tp *sdktrace.TracerProvider
+}
+
+func (c *otelProviderCloser) Close() error {
+	return c.tp.Shutdown(context.Background())
+}
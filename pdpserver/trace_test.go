@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestSetupZipkinV2EmitsJSONSpans(t *testing.T) {
+	var body []byte
+	done := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request body: %s", err)
+		}
+		close(done)
+	}))
+	defer srv.Close()
+
+	tracer, closer, err := setupZipkinV2(srv.URL)
+	if err != nil {
+		t.Fatalf("setupZipkinV2() returned error: %s", err)
+	}
+	defer closer.Close()
+
+	span := tracer.StartSpan("test-span")
+	ext.SpanKindRPCClient.Set(span)
+	span.SetTag("key", "value")
+	span.Finish()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for span to be reported")
+	}
+
+	var spans []map[string]interface{}
+	if err := json.Unmarshal(body, &spans); err != nil {
+		t.Fatalf("expected a JSON array of v2 spans, got error: %s, body: %s", err, body)
+	}
+
+	if len(spans) != 1 {
+		t.Fatalf("expected exactly one span, got %d", len(spans))
+	}
+
+	span0 := spans[0]
+	for _, field := range []string{"traceId", "id", "kind", "localEndpoint", "tags"} {
+		if _, ok := span0[field]; !ok {
+			t.Errorf("expected span to have field %q, got: %v", field, span0)
+		}
+	}
+}
+
+func TestTagInjectingTracerStartSpan(t *testing.T) {
+	tags := map[string]string{
+		"service.name": "PDP",
+		"host.name":    "test-host",
+		"process.pid":  "123",
+		"custom":       "value",
+	}
+
+	mt := mocktracer.New()
+	tracer := &tagInjectingTracer{Tracer: mt, tags: tags}
+
+	span := tracer.StartSpan("op")
+	span.Finish()
+
+	mockSpan, ok := span.(*mocktracer.MockSpan)
+	if !ok {
+		t.Fatalf("expected *mocktracer.MockSpan, got %T", span)
+	}
+
+	gotTags := mockSpan.Tags()
+	for k, want := range tags {
+		got, _ := gotTags[k].(string)
+		if got != want {
+			t.Errorf("expected tag %q = %q, got %q", k, want, got)
+		}
+	}
+}